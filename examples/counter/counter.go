@@ -0,0 +1,40 @@
+// Package counter is an example of a concurrent request counter service built on top of fastintmap.
+package counter
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+
+	"github.com/itsabgr/fastintmap"
+)
+
+// Counter counts occurrences of names.
+type Counter struct {
+	m fastintmap.Map[*int64]
+}
+
+func hash(name string) uintptr {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return uintptr(h.Sum64())
+}
+
+// Inc increases the counter of name by one and returns the new count.
+func (c *Counter) Inc(name string) int64 {
+	counter, _ := c.m.GetOrAdd(hash(name), new(int64))
+	return atomic.AddInt64(counter, 1)
+}
+
+// Count returns the current count of name.
+func (c *Counter) Count(name string) int64 {
+	counter, ok := c.m.Get(hash(name))
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(counter)
+}
+
+// Reset deletes the counter of name.
+func (c *Counter) Reset(name string) {
+	c.m.Delete(hash(name))
+}
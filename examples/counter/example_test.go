@@ -0,0 +1,33 @@
+package counter_test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/itsabgr/fastintmap/examples/counter"
+)
+
+func Example() {
+	var c counter.Counter
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc("/index.html")
+			}
+			c.Inc("/about.html")
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println("/index.html", c.Count("/index.html"))
+	fmt.Println("/about.html", c.Count("/about.html"))
+	c.Reset("/about.html")
+	fmt.Println("/about.html", c.Count("/about.html"))
+	// Output:
+	// /index.html 800
+	// /about.html 8
+	// /about.html 0
+}
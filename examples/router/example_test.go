@@ -0,0 +1,41 @@
+package router_test
+
+import (
+	"fmt"
+
+	"github.com/itsabgr/fastintmap/examples/router"
+)
+
+func Example() {
+	r := router.New(16)
+	r.Add("node-a")
+	r.Add("node-b")
+	r.Add("node-c")
+
+	keys := []string{"user:1", "user:2", "user:3", "user:4", "user:5"}
+	before := map[string]string{}
+	for _, key := range keys {
+		before[key], _ = r.Route(key)
+	}
+
+	r.Remove("node-b")
+	moved := 0
+	for _, key := range keys {
+		node, _ := r.Route(key)
+		if node == "node-b" {
+			fmt.Println("routed to removed node")
+		}
+		if node != before[key] && before[key] != "node-b" {
+			moved++
+		}
+	}
+	fmt.Println("keys moved between remaining nodes:", moved)
+
+	r.Remove("node-a")
+	r.Remove("node-c")
+	_, ok := r.Route("user:1")
+	fmt.Println("routable:", ok)
+	// Output:
+	// keys moved between remaining nodes: 0
+	// routable: false
+}
@@ -0,0 +1,86 @@
+// Package router is an example of a consistent hash router built on top of fastintmap.
+package router
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/itsabgr/fastintmap"
+)
+
+// Router routes keys to nodes using a consistent hash ring.
+// The map holds the node of every ring point, a sorted copy of the points and their nodes is used
+// to route as the map can not be searched in key order.
+type Router struct {
+	ring     fastintmap.Map[string]
+	points   atomic.Value // sorted []ringPoint of the ring, replaced on every change
+	mu       sync.Mutex   // serializes changes of the ring
+	replicas int
+}
+
+// ringPoint is a point of the ring and the node placed on it.
+type ringPoint struct {
+	point uintptr
+	node  string
+}
+
+// New returns a router placing every node replicas times on the ring.
+func New(replicas int) *Router {
+	r := &Router{replicas: replicas}
+	r.points.Store([]ringPoint(nil))
+	return r
+}
+
+func hash(s string) uintptr {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return uintptr(h.Sum64())
+}
+
+// Add places node on the ring.
+func (r *Router) Add(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < r.replicas; i++ {
+		r.ring.Set(hash(node+"#"+strconv.Itoa(i)), node)
+	}
+	r.updatePoints()
+}
+
+// Remove takes node off the ring.
+func (r *Router) Remove(node string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < r.replicas; i++ {
+		r.ring.Delete(hash(node + "#" + strconv.Itoa(i)))
+	}
+	r.updatePoints()
+}
+
+// updatePoints publishes the sorted points of the ring with their nodes, the map is visited in key order.
+func (r *Router) updatePoints() {
+	points := make([]ringPoint, 0, r.ring.Len())
+	_ = r.ring.Visit(func(point uintptr, node string) error {
+		points = append(points, ringPoint{point: point, node: node})
+		return nil
+	})
+	r.points.Store(points)
+}
+
+// Route returns the node owning key, or false if the ring is empty.
+// It only reads the published snapshot, so a concurrent change of the ring can not make it fail.
+func (r *Router) Route(key string) (node string, ok bool) {
+	points := r.points.Load().([]ringPoint)
+	if len(points) == 0 {
+		return "", false
+	}
+	h := hash(key)
+	i := sort.Search(len(points), func(i int) bool { return points[i].point >= h })
+	if i == len(points) {
+		i = 0 // wrap around to the start of the ring
+	}
+	return points[i].node, true
+}
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRouteEmptyNodeName(t *testing.T) {
+	r := New(4)
+	if _, ok := r.Route("key"); ok {
+		t.Error("empty ring should not route.")
+	}
+	r.Add("")
+	if node, ok := r.Route("key"); !ok || node != "" {
+		t.Errorf("key should be routed to the node with the empty name but got %q %t.", node, ok)
+	}
+	r.Remove("")
+	if _, ok := r.Route("key"); ok {
+		t.Error("empty ring should not route.")
+	}
+}
+
+func TestRouteConcurrentRemove(t *testing.T) {
+	r := New(16)
+	r.Add("a")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			r.Add("b")
+			r.Remove("b")
+		}
+	}()
+	for i := 0; i < 20000; i++ {
+		if node, ok := r.Route(strconv.Itoa(i)); !ok || (node != "a" && node != "b") {
+			t.Fatalf("key should be routed while nodes change but got %q %t.", node, ok)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,32 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/itsabgr/fastintmap"
+	"github.com/itsabgr/fastintmap/examples/snapshot"
+)
+
+func Example() {
+	m := &fastintmap.Map[string]{}
+	m.Set(3, "three")
+	m.Set(1, "one")
+	m.Set(2, "two")
+
+	var buf bytes.Buffer
+	if err := snapshot.Save(m, &buf); err != nil {
+		panic(err)
+	}
+
+	restored, err := snapshot.Load[string](&buf)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(restored.Len(), restored)
+	value, _ := restored.Get(2)
+	fmt.Println(value)
+	// Output:
+	// 3 [1,2,3]
+	// two
+}
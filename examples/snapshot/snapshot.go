@@ -0,0 +1,44 @@
+// Package snapshot is an example of saving and restoring the content of a fastintmap.
+package snapshot
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"runtime"
+
+	"github.com/itsabgr/fastintmap"
+)
+
+type record[T any] struct {
+	Key   uint64
+	Value T
+}
+
+// Save writes all entries of m to w in key order.
+// Entries changed concurrently may or may not be part of the snapshot.
+func Save[T any](m *fastintmap.Map[T], w io.Writer) error {
+	enc := gob.NewEncoder(w)
+	return m.Visit(func(key uintptr, value T) error {
+		return enc.Encode(record[T]{Key: uint64(key), Value: value})
+	})
+}
+
+// Load reads a snapshot written by Save into a new map.
+// The index of the map is prewarmed before it is returned.
+func Load[T any](r io.Reader) (*fastintmap.Map[T], error) {
+	dec := gob.NewDecoder(r)
+	m := &fastintmap.Map[T]{}
+	for {
+		var rec record[T]
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			m.Prewarm(runtime.GOMAXPROCS(0))
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.Set(uintptr(rec.Key), rec.Value)
+	}
+}
@@ -0,0 +1,131 @@
+// Package ttlcache is an example of a cache with expiring entries built on top of fastintmap.
+package ttlcache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/itsabgr/fastintmap"
+)
+
+// Options controls the expiration of an entry.
+type Options struct {
+	// TTL is the time to live of the entry after it has been set, or last read if Sliding is true.
+	TTL time.Duration
+	// Sliding extends the deadline of the entry by TTL on every successful Get.
+	Sliding bool
+	// MaxLifetime caps the lifetime of a sliding entry since it has been set, 0 means no cap.
+	MaxLifetime time.Duration
+}
+
+type entry[T any] struct {
+	deadline    int64 // unix nano timestamp after which the entry is expired, first for 64-bit alignment on 32-bit platforms
+	value       T
+	ttl         int64
+	sliding     bool
+	maxDeadline int64 // unix nano timestamp the deadline can not be extended beyond, 0 means no cap
+}
+
+func (e *entry[T]) expired(now int64) bool {
+	return atomic.LoadInt64(&e.deadline) <= now
+}
+
+// touch extends the deadline of a sliding entry after it has been read.
+func (e *entry[T]) touch(now int64) {
+	if !e.sliding {
+		return
+	}
+	deadline := now + e.ttl
+	if e.maxDeadline != 0 && deadline > e.maxDeadline {
+		deadline = e.maxDeadline
+	}
+	for {
+		current := atomic.LoadInt64(&e.deadline)
+		if current >= deadline || atomic.CompareAndSwapInt64(&e.deadline, current, deadline) {
+			return
+		}
+	}
+}
+
+// Cache is a map whose entries expire after a time to live.
+type Cache[T any] struct {
+	m    fastintmap.Map[*entry[T]]
+	opts Options
+	// Clock returns the current time, it defaults to time.Now.
+	Clock func() time.Time
+}
+
+// New returns a cache whose entries expire ttl after they have been set.
+func New[T any](ttl time.Duration) *Cache[T] {
+	return NewWithOptions[T](Options{TTL: ttl})
+}
+
+// NewSliding returns a cache whose entries expire ttl after they have been set or last read,
+// but no later than maxLifetime after they have been set. A maxLifetime of 0 means no cap.
+func NewSliding[T any](ttl, maxLifetime time.Duration) *Cache[T] {
+	return NewWithOptions[T](Options{TTL: ttl, Sliding: true, MaxLifetime: maxLifetime})
+}
+
+// NewWithOptions returns a cache applying opts to all entries not set with their own options.
+func NewWithOptions[T any](opts Options) *Cache[T] {
+	return &Cache[T]{opts: opts, Clock: time.Now}
+}
+
+// Options returns the default options of the cache, to be adjusted for SetWithOptions.
+func (c *Cache[T]) Options() Options {
+	return c.opts
+}
+
+func (c *Cache[T]) now() int64 {
+	return c.Clock().UnixNano()
+}
+
+// Set stores the value under key, replacing any previous entry and its deadline.
+func (c *Cache[T]) Set(key uintptr, value T) {
+	c.SetWithOptions(key, value, c.opts)
+}
+
+// SetWithOptions stores the value under key with its own expiration options,
+// replacing any previous entry and its deadline.
+func (c *Cache[T]) SetWithOptions(key uintptr, value T, opts Options) {
+	now := c.now()
+	e := &entry[T]{
+		value:    value,
+		deadline: now + int64(opts.TTL),
+		ttl:      int64(opts.TTL),
+		sliding:  opts.Sliding,
+	}
+	if opts.Sliding && opts.MaxLifetime > 0 {
+		e.maxDeadline = now + int64(opts.MaxLifetime)
+	}
+	c.m.Set(key, e)
+}
+
+// Get returns the value stored under key if it has not expired yet.
+// The deadline of a sliding entry is extended by its time to live.
+func (c *Cache[T]) Get(key uintptr) (value T, ok bool) {
+	e, ok := c.m.Get(key)
+	if !ok {
+		return value, false
+	}
+	now := c.now()
+	if e.expired(now) {
+		c.m.DeleteIf(key, func(current *entry[T]) bool { return current == e }) // keep a concurrently set entry
+		return value, false
+	}
+	e.touch(now)
+	return e.value, true
+}
+
+// Len returns the number of entries within the cache, including expired ones not swept yet.
+func (c *Cache[T]) Len() int {
+	return c.m.Len()
+}
+
+// Sweep deletes all expired entries and returns their count.
+func (c *Cache[T]) Sweep() int {
+	now := c.now()
+	return c.m.Shed(func(_ uintptr, e *entry[T]) bool {
+		return e.expired(now)
+	})
+}
@@ -0,0 +1,29 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetKeepsConcurrentlySetEntry(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := New[string](time.Minute)
+	cache.Clock = func() time.Time { return now }
+	cache.Set(1, "expired")
+	now = now.Add(2 * time.Minute)
+
+	refreshed := false
+	cache.Clock = func() time.Time {
+		if !refreshed { // set between the lookup of the expired entry and its deletion
+			refreshed = true
+			cache.Set(1, "fresh")
+		}
+		return now
+	}
+	if _, ok := cache.Get(1); ok {
+		t.Error("expired entry should not be returned.")
+	}
+	if value, ok := cache.Get(1); !ok || value != "fresh" {
+		t.Errorf("concurrently set entry should be kept but got %q %t.", value, ok)
+	}
+}
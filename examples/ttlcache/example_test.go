@@ -0,0 +1,68 @@
+package ttlcache_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/itsabgr/fastintmap/examples/ttlcache"
+)
+
+func Example() {
+	now := time.Unix(0, 0)
+	cache := ttlcache.New[string](time.Minute)
+	cache.Clock = func() time.Time { return now }
+
+	cache.Set(1, "session-1")
+	now = now.Add(30 * time.Second)
+	cache.Set(2, "session-2")
+
+	value, ok := cache.Get(1)
+	fmt.Println(value, ok)
+
+	now = now.Add(45 * time.Second)
+	_, ok = cache.Get(1)
+	fmt.Println("session-1 alive:", ok)
+	value, ok = cache.Get(2)
+	fmt.Println(value, ok)
+
+	now = now.Add(time.Minute)
+	fmt.Println("swept:", cache.Sweep(), "left:", cache.Len())
+	// Output:
+	// session-1 true
+	// session-1 alive: false
+	// session-2 true
+	// swept: 1 left: 0
+}
+
+func ExampleNewSliding() {
+	now := time.Unix(0, 0)
+	sessions := ttlcache.NewSliding[string](10*time.Minute, time.Hour)
+	sessions.Clock = func() time.Time { return now }
+
+	sessions.Set(1, "alice")
+	// a session with a fixed lifetime despite the sliding default
+	opts := sessions.Options()
+	opts.Sliding = false
+	sessions.SetWithOptions(2, "bob", opts)
+
+	for i := 0; i < 5; i++ { // keep the sessions busy for 40 minutes
+		now = now.Add(8 * time.Minute)
+		sessions.Get(1)
+		sessions.Get(2)
+	}
+	_, ok := sessions.Get(1)
+	fmt.Println("alice alive:", ok)
+	_, ok = sessions.Get(2)
+	fmt.Println("bob alive:", ok)
+
+	for i := 0; i < 3; i++ { // 24 more minutes exceeds the maximum lifetime of an hour
+		now = now.Add(8 * time.Minute)
+		sessions.Get(1)
+	}
+	_, ok = sessions.Get(1)
+	fmt.Println("alice alive:", ok)
+	// Output:
+	// alice alive: true
+	// bob alive: false
+	// alice alive: false
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/itsabgr/fastintmap/pkg/sortedlist"
 	"github.com/itsabgr/go-handy"
 	"reflect"
+	"runtime"
 	"strconv"
 	"sync/atomic"
 	"unsafe"
@@ -17,6 +18,9 @@ const DefaultSize = 8
 // MaxFillRate is the maximum fill rate for the slice before a resize  will happen.
 const MaxFillRate = float64(0.5)
 
+// DefaultYieldInterval is the default number of elements long internal walks visit before yielding the processor.
+const DefaultYieldInterval = 1 << 14
+
 type (
 	hashMapData struct {
 		keyShifts uintptr                   // Pointer size - log2 of array size, to be used as index in the data array
@@ -27,10 +31,15 @@ type (
 
 	// Map implements a read optimized hash map.
 	Map[T any] struct {
-		_noCopy  handy.NoCopy
-		dataMap  unsafe.Pointer // pointer to a map instance that gets replaced if the map resizes
-		listPtr  unsafe.Pointer // key sorted linked list of elements
-		resizing uintptr        // flag that marks a resizing operation in progress
+		_noCopy    handy.NoCopy
+		dataMap    unsafe.Pointer // pointer to a map instance that gets replaced if the map resizes
+		listPtr    unsafe.Pointer // key sorted linked list of elements
+		resizing   uintptr        // flag that marks a resizing operation in progress
+		version    uintptr        // schema version of the stored values
+		migrating  uintptr        // flag that marks a migration in progress
+		migration  unsafe.Pointer // map that a running migration moves the entries to
+		yieldEvery uintptr        // number of elements walked between yields, 0 means DefaultYieldInterval
+		gosched    func()         // yields the processor, nil means runtime.Gosched, replaced in tests
 	}
 )
 
@@ -44,6 +53,9 @@ func New[T any](size uintptr) *Map[T] {
 // Len returns the number of elements within the map.
 func (m *Map[T]) Len() int {
 	list := m.list()
+	if target := m.migrationTarget(); target != nil && target.list() != list {
+		return list.Len() + target.Len()
+	}
 	return list.Len()
 }
 
@@ -90,6 +102,9 @@ func (m *Map[T]) indexElement(hashedKey uintptr) (data *hashMapData, item *sorte
 	index := hashedKey >> data.keyShifts
 	ptr := (*unsafe.Pointer)(unsafe.Pointer(uintptr(data.data) + index*intSizeBytes))
 	item = (*sortedlist.ListElement)(atomic.LoadPointer(ptr))
+	if item != nil && item.Deleted() { // index is updated concurrently, look up the first item of the slice index
+		item = m.list().Search(item, item.Key())
+	}
 	return data, item
 }
 
@@ -111,46 +126,70 @@ func (m *Map[T]) searchItem(item *ListElement, key T, keyHash uintptr) (value T,
 */
 
 // Delete deletes the hashed key from the map.
+// The element of the key is unlinked when Delete returns, a concurrent Add or Set of the key links a new
+// element only after that.
 func (m *Map[T]) Delete(hashedKey uintptr) {
+	m.DeleteAndGet(hashedKey)
+}
+
+// DeleteAndGet deletes the hashed key from the map and returns the removed value.
+// When the key is deleted concurrently only one caller gets ok true, so the value can be
+// safely handed back to a pool for reuse.
+func (m *Map[T]) DeleteAndGet(hashedKey uintptr) (value T, ok bool) {
 	list := m.list()
-	if list == nil {
-		return
+	element := m.searchElement(hashedKey)
+	if element == nil {
+		return value, false
+	}
+	if !list.Delete(element) {
+		return value, false
 	}
+	m.deleteElement(element)
+	return cast[T](element.Value()), true
+}
 
-	// inline Map[T].searchItem()
-	var element *sortedlist.ListElement
-ElementLoop:
-	for _, element = m.indexElement(hashedKey); element != nil; element = element.Next() {
-		if element.Key() == hashedKey {
+// DeleteIf deletes the hashed key from the map if fn returns true for its value and the value
+// did not change until the key got deleted. Returns true if the key was deleted.
+func (m *Map[T]) DeleteIf(hashedKey uintptr, fn func(value T) bool) bool {
+	list := m.list()
+	element := m.searchElement(hashedKey)
+	if element == nil {
+		return false
+	}
+	if !list.DeleteIf(element, func(value interface{}) bool { return fn(cast[T](value)) }) {
+		return false
+	}
+	m.deleteElement(element)
+	return true
+}
 
-			break ElementLoop
+// searchElement returns the list element of the hashed key or nil if it does not exist.
+func (m *Map[T]) searchElement(hashedKey uintptr) *sortedlist.ListElement {
+	if m.list() == nil {
+		return nil
+	}
 
+	// inline Map[T].searchItem()
+	for _, element := m.indexElement(hashedKey); element != nil; element = element.Next() {
+		if element.Key() == hashedKey {
+			return element
 		}
 
 		if element.Key() > hashedKey {
-			return
+			return nil
 		}
 	}
-
-	if element == nil {
-		return
-	}
-	m.deleteElement(element)
-	list.Delete(element)
+	return nil
 }
 
-// deleteElement deletes an element from index
+// deleteElement deletes an element that got deleted from the list from index
 func (m *Map[T]) deleteElement(element *sortedlist.ListElement) {
+	list := m.list()
 	for {
 		data := m.mapData()
 		index := element.Key() >> data.keyShifts
 		ptr := (*unsafe.Pointer)(unsafe.Pointer(uintptr(data.data) + index*intSizeBytes))
-
-		next := element.Next()
-		if next != nil && element.Key()>>data.keyShifts != index {
-			next = nil // do not set index to next item if it's not the same slice index
-		}
-		atomic.CompareAndSwapPointer(ptr, unsafe.Pointer(element), unsafe.Pointer(next))
+		data.replaceDeleted(list, ptr, element)
 
 		currentData := m.mapData()
 		if data == currentData { // check that no resize happened
@@ -162,6 +201,8 @@ func (m *Map[T]) deleteElement(element *sortedlist.ListElement) {
 // Add sets the value under the specified key to the map if it does not exist yet.
 // If a resizing operation is happening concurrently while calling Set, the item might show up in the map only after the resize operation is finished.
 // Returns true if the item was inserted or false if it existed.
+// The map holds at most one element per key: if the key is being deleted concurrently, the new element
+// is only linked after the deleted one has been unlinked.
 func (m *Map[T]) Add(key uintptr, value T) bool {
 	element := sortedlist.NewElement(key, value)
 	return m.insertListElement(element, false)
@@ -169,6 +210,7 @@ func (m *Map[T]) Add(key uintptr, value T) bool {
 
 // Set sets the value under the specified key to the map. An existing item for this key will be overwritten.
 // If a resizing operation is happening concurrently while calling Set, the item might show up in the map only after the resize operation is finished.
+// Like Add, Set links a new element for a key that is being deleted only after the deleted one has been unlinked.
 func (m *Map[T]) Set(key uintptr, value T) {
 
 	element := sortedlist.NewElement(key, value)
@@ -185,7 +227,11 @@ func (m *Map[T]) insertListElement(element *sortedlist.ListElement, update bool)
 		list := m.list()
 
 		if update {
-			if !list.AddOrUpdate(element, existing) {
+			updated, inserted := list.AddOrUpdate(element, existing)
+			if updated {
+				return true
+			}
+			if !inserted {
 				continue // a concurrent add did interfere, try again
 			}
 		} else {
@@ -198,7 +244,11 @@ func (m *Map[T]) insertListElement(element *sortedlist.ListElement, update bool)
 			}
 		}
 
-		count := data.addItemToIndex(element)
+		count := data.addItemToIndex(list, element)
+		for current := m.mapData(); current != data; current = m.mapData() { // a resize happened concurrently
+			data = current
+			count = data.addItemToIndex(list, element)
+		}
 		if m.resizeNeeded(data, count) {
 			if atomic.CompareAndSwapUintptr(&m.resizing, uintptr(0), uintptr(1)) {
 				go m.grow(0, true)
@@ -209,6 +259,7 @@ func (m *Map[T]) insertListElement(element *sortedlist.ListElement, update bool)
 }
 
 // CAS performs a compare and swap operation sets the value under the specified key to the map. An existing item for this key will be overwritten.
+// The swap fails if the element of the key is being deleted, it never creates a second element for the key.
 func (m *Map[T]) CAS(key uintptr, from, to T) bool {
 	data, existing := m.indexElement(key)
 	if data == nil {
@@ -223,14 +274,28 @@ func (m *Map[T]) CAS(key uintptr, from, to T) bool {
 	return list.Cas(element, from, existing)
 }
 
+// replaceDeleted replaces the deleted element in the index with the next item of the same slice index.
+func (mapData *hashMapData) replaceDeleted(list *sortedlist.List, ptr *unsafe.Pointer, element *sortedlist.ListElement) {
+	index := element.Key() >> mapData.keyShifts
+	next := list.Search(element, element.Key()) // the list is searched as a deleted element is not linked to new items
+	if next != nil && next.Key()>>mapData.keyShifts != index {
+		next = nil // do not set index to next item if it's not the same slice index
+	}
+	atomic.CompareAndSwapPointer(ptr, unsafe.Pointer(element), unsafe.Pointer(next))
+}
+
 // adds an item to the index if needed and returns the new item counter if it changed, otherwise 0
-func (mapData *hashMapData) addItemToIndex(item *sortedlist.ListElement) uintptr {
+func (mapData *hashMapData) addItemToIndex(list *sortedlist.List, item *sortedlist.ListElement) uintptr {
 	index := item.Key() >> mapData.keyShifts
 	ptr := (*unsafe.Pointer)(unsafe.Pointer(uintptr(mapData.data) + index*intSizeBytes))
 
 	for { // loop until the smallest key hash is in the index
 		element := (*sortedlist.ListElement)(atomic.LoadPointer(ptr)) // get the current item in the index
-		if element == nil {                                           // no item yet at this index
+		if element != nil && element.Deleted() {                      // deleted item that might not be linked to the new item
+			mapData.replaceDeleted(list, ptr, element)
+			continue
+		}
+		if element == nil { // no item yet at this index
 			if atomic.CompareAndSwapPointer(ptr, nil, unsafe.Pointer(item)) {
 				return atomic.AddUintptr(&mapData.count, 1)
 			}
@@ -304,17 +369,56 @@ func (m *Map[T]) fillIndexItems(mapData *hashMapData) {
 	first := list.First()
 	item := first
 	lastIndex := uintptr(0)
+	yield := m.yielder()
 
 	for item != nil {
 		index := item.Key() >> mapData.keyShifts
 		if item == first || index != lastIndex { // store item with smallest hash key for every index
-			mapData.addItemToIndex(item)
+			mapData.addItemToIndex(list, item)
 			lastIndex = index
 		}
 		item = item.Next()
+		yield()
+	}
+}
+
+// SetYieldInterval sets the number of elements that long internal walks like resizing visit
+// before yielding the processor to other goroutines. 0 restores DefaultYieldInterval.
+func (m *Map[T]) SetYieldInterval(n uintptr) {
+	atomic.StoreUintptr(&m.yieldEvery, n)
+}
+
+// yielder returns a function to be called once per visited element which periodically calls runtime.Gosched.
+func (m *Map[T]) yielder() func() {
+	every := atomic.LoadUintptr(&m.yieldEvery)
+	if every == 0 {
+		every = DefaultYieldInterval
+	}
+	gosched := m.gosched
+	if gosched == nil {
+		gosched = runtime.Gosched
+	}
+	budget := every
+	return func() {
+		budget--
+		if budget == 0 {
+			gosched()
+			budget = every
+		}
 	}
 }
 
+// DedupCheck verifies that every key exists at most once within the map, that the keys are sorted
+// and that Len matches the number of visited entries. It is a debug helper and must not be called
+// concurrently to modifications of the map.
+func (m *Map[T]) DedupCheck() error {
+	list := m.list()
+	if list == nil {
+		return nil
+	}
+	return list.Check()
+}
+
 // String returns the map as a string, only hashed keys are printed.
 func (m *Map[T]) String() string {
 	list := m.list()
@@ -339,7 +443,8 @@ func (m *Map[T]) String() string {
 	return buffer.String()
 }
 
-//Visit visits the entries in key order, calling fn for each. if the fn returns non-nil error stops process and returns that error
+// Visit visits the entries in key order, calling fn for each. if the fn returns non-nil error stops process and returns that error
+// During a migration the entries that are migrated already are visited after the other entries.
 func (m *Map[T]) Visit(fn func(key uintptr, value T) error) error {
 	list := m.list()
 	if list == nil {
@@ -354,5 +459,8 @@ func (m *Map[T]) Visit(fn func(key uintptr, value T) error) error {
 		}
 		item = item.Next()
 	}
+	if target := m.migrationTarget(); target != nil && target.list() != list {
+		return target.Visit(fn)
+	}
 	return nil
 }
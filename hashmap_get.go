@@ -15,6 +15,15 @@ func cast[T any](t interface{}) T {
 
 // Get retrieves an element from the map under given hashed key.
 func (m *Map[T]) Get(key uintptr) (value T, ok bool) {
+	if value, ok = m.get(key); !ok {
+		if target := m.migrationTarget(); target != nil {
+			return target.get(key)
+		}
+	}
+	return value, ok
+}
+
+func (m *Map[T]) get(key uintptr) (value T, ok bool) {
 	data, element := m.indexElement(key)
 	if data == nil {
 		return value, false
@@ -23,6 +32,9 @@ func (m *Map[T]) Get(key uintptr) (value T, ok bool) {
 	// inline Map.searchItem()
 	for element != nil {
 		if element.Key() == key {
+			if element.Deleted() {
+				return value, false
+			}
 			return cast[T](element.Value()), true
 		}
 
@@ -52,7 +64,7 @@ func (m *Map[T]) GetOrAdd(key uintptr, value T) (actual T, loaded bool) {
 		for element != nil {
 			if element.Key() == h {
 
-				if element.Key() == key {
+				if element.Key() == key && !element.Deleted() {
 					actual = cast[T](element.Value())
 					return actual, true
 
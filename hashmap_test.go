@@ -236,3 +236,162 @@ func TestHashMap_SetConcurrent(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestDeleteAndGet(t *testing.T) {
+	m := &Map[*Animal]{}
+	if _, ok := m.DeleteAndGet(1); ok {
+		t.Error("empty map should not return an item.")
+	}
+	elephant := &Animal{"elephant"}
+	m.Set(1, elephant)
+
+	var wg sync.WaitGroup
+	var deleted int64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, ok := m.DeleteAndGet(1)
+			if !ok {
+				return
+			}
+			atomic.AddInt64(&deleted, 1)
+			if value != elephant {
+				t.Error("wrong item returned.")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if deleted != 1 {
+		t.Errorf("item should be returned exactly once but was returned %d times.", deleted)
+	}
+	if m.Len() != 0 {
+		t.Error("Map is not empty.")
+	}
+}
+
+func TestYieldInterval(t *testing.T) {
+	yields := 0
+	gosched := func() { yields++ }
+
+	m := &Map[int]{gosched: gosched}
+	yield := m.yielder()
+	for i := 0; i < DefaultYieldInterval*2+1; i++ {
+		yield()
+	}
+	if yields != 2 {
+		t.Errorf("expected 2 yields with the default interval but got %d.", yields)
+	}
+
+	yields = 0
+	m.SetYieldInterval(3)
+	yield = m.yielder()
+	for i := 0; i < 10; i++ {
+		yield()
+	}
+	if yields != 3 {
+		t.Errorf("expected 3 yields with interval 3 but got %d.", yields)
+	}
+
+	m = New[int](64) // large enough to not resize in the background
+	m.gosched = gosched
+	m.SetYieldInterval(3)
+	for i := 0; i < 10; i++ {
+		m.Set(uintptr(i)<<(strconv.IntSize-4), i)
+	}
+	yields = 0
+	m.grow(64, false) // the index walk yields after every third element
+	if yields != 10/3*2 {
+		t.Errorf("expected %d yields while filling the index twice but got %d.", 10/3*2, yields)
+	}
+}
+
+func TestDedupCheckConcurrent(t *testing.T) {
+	m := &Map[int]{}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				key := uintptr(i%64) << (strconv.IntSize - 6)
+				switch (i + g) % 4 {
+				case 0:
+					m.Add(key, i)
+				case 1:
+					m.Set(key, i)
+				case 2:
+					m.Delete(key)
+				case 3:
+					m.CAS(key, i-1, i)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := m.DedupCheck(); err != nil {
+		t.Fatal(err)
+	}
+	visited := map[uintptr]int{}
+	_ = m.Visit(func(key uintptr, value int) error {
+		visited[key] = value
+		return nil
+	})
+	if len(visited) != m.Len() {
+		t.Errorf("visited %d entries but Len is %d.", len(visited), m.Len())
+	}
+
+	// the index has to agree with the list for every key after the concurrent modifications
+	for i := 0; i < 64; i++ {
+		key := uintptr(i) << (strconv.IntSize - 6)
+		expected, exists := visited[key]
+		if value, ok := m.Get(key); ok != exists || value != expected {
+			t.Errorf("Get(%d) returned %d %t but the list contains %d %t.", key, value, ok, expected, exists)
+		}
+		m.Set(key, -i)
+		if value, ok := m.Get(key); !ok || value != -i {
+			t.Errorf("Get(%d) after Set returned %d %t.", key, value, ok)
+		}
+		if value, ok := m.DeleteAndGet(key); !ok || value != -i {
+			t.Errorf("DeleteAndGet(%d) returned %d %t.", key, value, ok)
+		}
+		if _, ok := m.Get(key); ok {
+			t.Errorf("key %d should not exist after Delete.", key)
+		}
+	}
+	if m.Len() != 0 {
+		t.Errorf("map should be empty but has %d items.", m.Len())
+	}
+	if err := m.DedupCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteIf(t *testing.T) {
+	m := &Map[*Animal]{}
+	elephant := &Animal{"elephant"}
+	monkey := &Animal{"monkey"}
+	if m.DeleteIf(1, func(*Animal) bool { return true }) {
+		t.Error("empty map should not delete an item.")
+	}
+	m.Set(1, elephant)
+
+	if m.DeleteIf(1, func(value *Animal) bool { return value == monkey }) {
+		t.Error("item should not be deleted if fn returns false.")
+	}
+	deleted := m.DeleteIf(1, func(value *Animal) bool {
+		m.Set(1, monkey) // replaced after fn decided on the old value
+		return value == elephant
+	})
+	if deleted {
+		t.Error("item should not be deleted if its value changed.")
+	}
+	if !m.DeleteIf(1, func(value *Animal) bool { return value == monkey }) {
+		t.Error("item should be deleted if fn returns true.")
+	}
+	if _, ok := m.Get(1); ok || m.Len() != 0 {
+		t.Error("Map is not empty.")
+	}
+}
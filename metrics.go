@@ -0,0 +1,41 @@
+package fastintmap
+
+import (
+	"sync/atomic"
+)
+
+// Stable metric names reported by Collect, following the runtime/metrics naming scheme.
+const (
+	MetricEntries   = "/fastintmap/entries:elements"
+	MetricBuckets   = "/fastintmap/index/buckets:buckets"
+	MetricFilled    = "/fastintmap/index/filled:buckets"
+	MetricFillRate  = "/fastintmap/index/fill-rate:ratio"
+	MetricResizing  = "/fastintmap/index/resizing:bool"
+	MetricShiftBits = "/fastintmap/index/key-shift:bits"
+)
+
+// Metric is a single named sample of the map health.
+type Metric struct {
+	Name  string
+	Value float64
+}
+
+// Collect sends a sample of every metric of the map to ch.
+// It blocks until all samples are received, ch is not closed.
+func (m *Map[T]) Collect(ch chan<- Metric) {
+	var buckets, filled, fillRate, shift float64
+	if data := m.mapData(); data != nil {
+		buckets = float64(len(data.index))
+		filled = float64(atomic.LoadUintptr(&data.count))
+		if buckets > 0 {
+			fillRate = filled / buckets
+		}
+		shift = float64(data.keyShifts)
+	}
+	ch <- Metric{Name: MetricEntries, Value: float64(m.Len())}
+	ch <- Metric{Name: MetricBuckets, Value: buckets}
+	ch <- Metric{Name: MetricFilled, Value: filled}
+	ch <- Metric{Name: MetricFillRate, Value: fillRate}
+	ch <- Metric{Name: MetricResizing, Value: float64(atomic.LoadUintptr(&m.resizing))}
+	ch <- Metric{Name: MetricShiftBits, Value: shift}
+}
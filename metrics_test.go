@@ -0,0 +1,44 @@
+package fastintmap
+
+import (
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	m := New[int](8)
+	for i := 0; i < 3; i++ {
+		m.Set(uintptr(i), i)
+	}
+
+	ch := make(chan Metric)
+	go func() {
+		m.Collect(ch)
+		close(ch)
+	}()
+	samples := map[string]float64{}
+	for metric := range ch {
+		samples[metric.Name] = metric.Value
+	}
+
+	if samples[MetricEntries] != 3 {
+		t.Errorf("expected 3 entries but got %f.", samples[MetricEntries])
+	}
+	if samples[MetricBuckets] != 8 {
+		t.Errorf("expected 8 buckets but got %f.", samples[MetricBuckets])
+	}
+	if _, ok := samples[MetricFillRate]; !ok {
+		t.Error("fill rate metric is missing.")
+	}
+}
+
+func TestCollectEmpty(t *testing.T) {
+	m := &Map[int]{}
+	ch := make(chan Metric, 16)
+	m.Collect(ch)
+	close(ch)
+	for metric := range ch {
+		if metric.Value != 0 {
+			t.Errorf("metric %s of an empty map should be 0 but was %f.", metric.Name, metric.Value)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package fastintmap
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+var (
+	// ErrSchemaVersion is returned by Migrate if the map is not at the expected schema version.
+	ErrSchemaVersion = errors.New("fastintmap: schema version mismatch")
+	// ErrMigrationInProgress is returned by Migrate if another migration is running.
+	ErrMigrationInProgress = errors.New("fastintmap: migration in progress")
+	// ErrNilTransform is returned by Migrate if no transform function is given.
+	ErrNilTransform = errors.New("fastintmap: nil transform")
+)
+
+// SchemaVersion returns the schema version of the values stored in the map, 0 for a new map.
+// It changes to the target version once a migration has finished.
+func (m *Map[T]) SchemaVersion() int {
+	return int(atomic.LoadUintptr(&m.version))
+}
+
+// Migrate rewrites all entries of the map from schema version from to version to.
+// transform is called once for every entry in key order and returns the new key and value,
+// or false to drop the entry.
+//
+// Entries are moved one at a time into a new map that replaces the list and index of the map
+// once the walk is finished, so a new key never collides with an entry that is not transformed yet.
+// Two entries that transform to the same key keep the one visited last. Every entry is inserted into
+// the new map before it is removed from the old one, and Get looks in both until Migrate returns,
+// so readers running concurrently see every entry in either the old or the new schema.
+// Besides the entry being moved, the memory overhead is the index of the new map.
+// Writers have to be paused until Migrate returns, entries written concurrently may be lost.
+func (m *Map[T]) Migrate(from, to int, transform func(key uintptr, value T) (uintptr, T, bool)) error {
+	if transform == nil {
+		return ErrNilTransform
+	}
+	if !atomic.CompareAndSwapUintptr(&m.migrating, uintptr(0), uintptr(1)) {
+		return ErrMigrationInProgress
+	}
+	defer atomic.StoreUintptr(&m.migrating, uintptr(0))
+
+	if m.SchemaVersion() != from {
+		return ErrSchemaVersion
+	}
+
+	if list := m.list(); list != nil {
+		// keep the index of the map fixed until the new one is published
+		for !atomic.CompareAndSwapUintptr(&m.resizing, uintptr(0), uintptr(1)) {
+			runtime.Gosched()
+		}
+		defer atomic.StoreUintptr(&m.resizing, uintptr(0))
+
+		target := New[T](DefaultSize)
+		target.yieldEvery = atomic.LoadUintptr(&m.yieldEvery)
+		target.gosched = m.gosched
+		atomic.StorePointer(&m.migration, unsafe.Pointer(target))
+
+		item := list.First()
+		yield := m.yielder()
+		for item != nil {
+			key := item.Key()
+			newKey, newValue, keep := transform(key, cast[T](item.Value()))
+			if keep { // insert the moved entry first so that it is never missing
+				target.Set(newKey, newValue)
+			}
+			if list.Delete(item) {
+				m.deleteElement(item)
+			}
+			item = item.Next()
+			yield()
+		}
+
+		// wait for a resize of the new map, it would not be published after the swap
+		for !atomic.CompareAndSwapUintptr(&target.resizing, uintptr(0), uintptr(1)) {
+			runtime.Gosched()
+		}
+		// the new index finds its elements without the list, publish it first
+		atomic.StorePointer(&m.dataMap, atomic.LoadPointer(&target.dataMap))
+		atomic.StorePointer(&m.listPtr, atomic.LoadPointer(&target.listPtr))
+		atomic.StorePointer(&m.migration, nil)
+	}
+
+	atomic.StoreUintptr(&m.version, uintptr(to))
+	return nil
+}
+
+// migrationTarget returns the map that a running migration moves the entries to, or nil.
+func (m *Map[T]) migrationTarget() *Map[T] {
+	return (*Map[T])(atomic.LoadPointer(&m.migration))
+}
@@ -0,0 +1,173 @@
+package fastintmap
+
+import (
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 10; i++ {
+		m.Set(uintptr(i), i)
+	}
+	if m.SchemaVersion() != 0 {
+		t.Errorf("new map should be at schema version 0 but is at %d.", m.SchemaVersion())
+	}
+
+	err := m.Migrate(0, 1, func(key uintptr, value int) (uintptr, int, bool) {
+		switch {
+		case key == 0:
+			return key, value, false
+		case key%2 == 0:
+			return key + 100, value * 10, true
+		}
+		return key, value * 10, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SchemaVersion() != 1 {
+		t.Errorf("map should be at schema version 1 but is at %d.", m.SchemaVersion())
+	}
+	if m.Len() != 9 {
+		t.Errorf("expected 9 entries but got %d.", m.Len())
+	}
+	if _, ok := m.Get(0); ok {
+		t.Error("dropped entry should not exist.")
+	}
+	if value, ok := m.Get(3); !ok || value != 30 {
+		t.Errorf("expected rewritten value 30 but got %d.", value)
+	}
+	if _, ok := m.Get(4); ok {
+		t.Error("moved entry should not exist under its old key.")
+	}
+	if value, ok := m.Get(104); !ok || value != 40 {
+		t.Errorf("expected moved value 40 but got %d.", value)
+	}
+
+	identity := func(key uintptr, value int) (uintptr, int, bool) {
+		return key, value, true
+	}
+	if err = m.Migrate(0, 2, identity); err != ErrSchemaVersion {
+		t.Errorf("expected ErrSchemaVersion but got %v.", err)
+	}
+	if err = m.Migrate(1, 2, nil); err != ErrNilTransform {
+		t.Errorf("expected ErrNilTransform but got %v.", err)
+	}
+}
+
+func TestMigrateMoveAhead(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 100; i++ {
+		m.Set(uintptr(i), i)
+	}
+
+	calls := 0
+	err := m.Migrate(0, 1, func(key uintptr, value int) (uintptr, int, bool) {
+		calls++
+		if key < 50 {
+			return key + 1000, value + 1, true // move ahead of the walk
+		}
+		return key, value + 1, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 100 {
+		t.Errorf("transform should be called once per entry but was called %d times.", calls)
+	}
+	if m.Len() != 100 {
+		t.Errorf("expected 100 entries but got %d.", m.Len())
+	}
+	for i := 0; i < 100; i++ {
+		key := uintptr(i)
+		if i < 50 {
+			key += 1000
+		}
+		if value, ok := m.Get(key); !ok || value != i+1 {
+			t.Errorf("expected value %d under key %d but got %d %t.", i+1, key, value, ok)
+		}
+	}
+}
+
+func TestMigrateShift(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 10; i++ {
+		m.Set(uintptr(i), i)
+	}
+
+	calls := 0
+	err := m.Migrate(0, 1, func(key uintptr, value int) (uintptr, int, bool) {
+		calls++
+		return key + 1, value * 10, true // collides with the next entry that is not transformed yet
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 10 {
+		t.Errorf("transform should be called once per entry but was called %d times.", calls)
+	}
+	if m.Len() != 10 {
+		t.Errorf("expected 10 entries but got %d.", m.Len())
+	}
+	if _, ok := m.Get(0); ok {
+		t.Error("shifted entry should not exist under its old key.")
+	}
+	for i := 0; i < 10; i++ {
+		if value, ok := m.Get(uintptr(i + 1)); !ok || value != i*10 {
+			t.Errorf("expected value %d under key %d but got %d %t.", i*10, i+1, value, ok)
+		}
+	}
+}
+
+func TestMigrateSwap(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 10; i++ {
+		m.Set(uintptr(i), i)
+	}
+
+	err := m.Migrate(0, 1, func(key uintptr, value int) (uintptr, int, bool) {
+		return key ^ 1, value, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 10 {
+		t.Errorf("expected 10 entries but got %d.", m.Len())
+	}
+	for i := 0; i < 10; i++ {
+		if value, ok := m.Get(uintptr(i ^ 1)); !ok || value != i {
+			t.Errorf("expected value %d under key %d but got %d %t.", i, i^1, value, ok)
+		}
+	}
+}
+
+func TestMigrateConcurrentGet(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 100; i++ {
+		m.Set(uintptr(i), i)
+	}
+
+	err := m.Migrate(0, 1, func(key uintptr, value int) (uintptr, int, bool) {
+		if key > 0 {
+			if value, ok := m.Get(key + 999); !ok || value != int(key) {
+				t.Errorf("expected migrated value %d under key %d but got %d %t.", key, key+999, value, ok)
+			}
+		}
+		if value, ok := m.Get(key); !ok || value != int(key) {
+			t.Errorf("expected value %d under key %d but got %d %t.", key, key, value, ok)
+		}
+		if m.Len() != 100 {
+			t.Errorf("expected 100 entries during the migration but got %d.", m.Len())
+		}
+		return key + 1000, value + 1, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 100 {
+		t.Errorf("expected 100 entries but got %d.", m.Len())
+	}
+	if value, ok := m.Get(1099); !ok || value != 100 {
+		t.Errorf("expected value 100 but got %d %t.", value, ok)
+	}
+}
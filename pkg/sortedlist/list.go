@@ -1,12 +1,15 @@
 package sortedlist
 
 import (
+	"fmt"
 	"github.com/itsabgr/go-handy"
 	"sync/atomic"
 	"unsafe"
 )
 
 // List is a sorted doubly linked list.
+// It holds at most one element per key: an element is only linked after an element with the same key
+// that is being deleted has been unlinked, so Check never finds duplicates.
 type List struct {
 	_noCopy handy.NoCopy
 	count   uintptr
@@ -48,7 +51,7 @@ func (l *List) First() *ListElement {
 // Add adds an item to the list and returns false if an item for the hash existed.
 // searchStart = nil will start to search at the head item
 func (l *List) Add(element *ListElement, searchStart *ListElement) (existed bool, inserted bool) {
-	left, found, right := l.search(searchStart, element)
+	left, found, right := l.search(searchStart, element.Key())
 	if found != nil { // existing item found
 		return true, false
 	}
@@ -57,19 +60,23 @@ func (l *List) Add(element *ListElement, searchStart *ListElement) (existed bool
 }
 
 // AddOrUpdate adds or updates an item to the list.
-func (l *List) AddOrUpdate(element *ListElement, searchStart *ListElement) bool {
-	left, found, right := l.search(searchStart, element)
+// Returns updated true if the value of an existing item was replaced, inserted true if the element was added.
+// Both are false if a concurrent modification interfered and the operation needs to be retried.
+func (l *List) AddOrUpdate(element *ListElement, searchStart *ListElement) (updated bool, inserted bool) {
+	left, found, right := l.search(searchStart, element.Key())
 	if found != nil { // existing item found
-		found.setValue(element.value) // update the value
-		return true
+		if !found.setValue(element.value) { // update the value
+			return false, false // item was deleted concurrently
+		}
+		return true, false
 	}
 
-	return l.insertAt(element, left, right)
+	return false, l.insertAt(element, left, right)
 }
 
 // Cas compares and swaps the value of an item in the list.
 func (l *List) Cas(element *ListElement, oldValue interface{}, searchStart *ListElement) bool {
-	_, found, _ := l.search(searchStart, element)
+	_, found, _ := l.search(searchStart, element.Key())
 	if found == nil { // no existing item found
 		return false
 	}
@@ -80,103 +87,196 @@ func (l *List) Cas(element *ListElement, oldValue interface{}, searchStart *List
 	return false
 }
 
-func (l *List) search(searchStart *ListElement, item *ListElement) (left *ListElement, found *ListElement, right *ListElement) {
-	if searchStart != nil && item.key < searchStart.key { // key would remain left from item? {
-		searchStart = nil // start search at head
+// Search returns the first item that is not deleted and has a key equal or bigger than key,
+// or nil if no such item exists. searchStart = nil will start to search at the head item
+func (l *List) Search(searchStart *ListElement, key uintptr) *ListElement {
+	_, found, right := l.search(searchStart, key)
+	if found != nil {
+		return found
 	}
+	for right != nil && right.Deleted() {
+		right = right.Next()
+	}
+	return right
+}
 
-	if searchStart == nil { // start search at head?
-		left = l.head
-		found = left.Next()
-		if found == nil { // no items beside head?
-			return nil, nil, nil
-		}
-	} else {
-		found = searchStart
+// start returns the element to start a search for key at, which is searchStart or its predecessor
+// if their key is smaller than key and they are not being deleted, otherwise the head of the list.
+func (l *List) start(searchStart *ListElement, key uintptr) *ListElement {
+	if searchStart != nil && searchStart.Key() >= key {
+		searchStart = searchStart.Previous()
 	}
+	if searchStart == nil || searchStart.Key() >= key || searchStart.marked() {
+		return l.head
+	}
+	return searchStart
+}
 
+// search returns the linked element left of key and either the not deleted element with the key
+// or the element right of the key. Elements with the key that are deleted get unlinked first.
+func (l *List) search(searchStart *ListElement, key uintptr) (left *ListElement, found *ListElement, right *ListElement) {
+Retry:
 	for {
-		if item.key == found.key { // key already exists
-			return nil, found, nil
-		}
-
-		if item.key < found.key { // new item needs to be inserted before the found value
-			if l.head == left {
-				return nil, nil, found
+		left = l.start(searchStart, key)
+		var previous *ListElement // element left of left if known
+		for {
+			next := left.rawNext()
+			if next != nil && next.marker { // left is being deleted
+				if previous != nil { // help unlinking it
+					atomic.CompareAndSwapPointer(&previous.nextElement, unsafe.Pointer(left), unsafe.Pointer(next.rawNext()))
+				}
+				searchStart = nil
+				continue Retry
 			}
-			return left, nil, found
-		}
-
-		// go to next element in sorted linked list
-		left = found
-		found = left.Next()
-		if found == nil { // no more items on the right
-			return left, nil, nil
+			if next == nil || next.Key() > key {
+				return left, nil, next
+			}
+			if next.Key() == key {
+				if next.Deleted() { // make room for a new element with the same key
+					l.unlink(next)
+					searchStart = nil
+					continue Retry
+				}
+				return left, next, nil
+			}
+			previous, left = left, next
 		}
 	}
 }
 
+// insertAt links element between left and right. It fails if left is not linked to right anymore,
+// which includes left being deleted, so an element never gets linked to an unreachable item.
 func (l *List) insertAt(element *ListElement, left *ListElement, right *ListElement) bool {
-	if left == nil {
-		//element->previous = head
-		element.previousElement = unsafe.Pointer(l.head)
-		//element->next = right
-		element.nextElement = unsafe.Pointer(right)
-
-		// insert at head, head-->next = element
-		if !atomic.CompareAndSwapPointer(&l.head.nextElement, unsafe.Pointer(right), unsafe.Pointer(element)) {
-			return false // item was modified concurrently
-		}
+	element.previousElement = unsafe.Pointer(left)
+	element.nextElement = unsafe.Pointer(right)
 
-		//right->previous = element
-		if right != nil {
-			if !atomic.CompareAndSwapPointer(&right.previousElement, unsafe.Pointer(l.head), unsafe.Pointer(element)) {
-				return false // item was modified concurrently
-			}
-		}
-	} else {
-		element.previousElement = unsafe.Pointer(left)
-		element.nextElement = unsafe.Pointer(right)
-
-		if !atomic.CompareAndSwapPointer(&left.nextElement, unsafe.Pointer(right), unsafe.Pointer(element)) {
-			return false // item was modified concurrently
-		}
+	if !atomic.CompareAndSwapPointer(&left.nextElement, unsafe.Pointer(right), unsafe.Pointer(element)) {
+		return false // item was modified concurrently
+	}
 
-		if right != nil {
-			if !atomic.CompareAndSwapPointer(&right.previousElement, unsafe.Pointer(left), unsafe.Pointer(element)) {
-				return false // item was modified concurrently
-			}
-		}
+	// right->previous = element, previous pointers are only hints for the search start
+	if right != nil {
+		atomic.CompareAndSwapPointer(&right.previousElement, unsafe.Pointer(left), unsafe.Pointer(element))
 	}
 
 	atomic.AddUintptr(&l.count, 1)
 	return true
 }
 
-// Delete deletes an element from the list.
-func (l *List) Delete(element *ListElement) {
+// Delete deletes an element from the list and returns false if it was deleted concurrently.
+// The element is unlinked from the list when Delete returns and its value does not change anymore.
+func (l *List) Delete(element *ListElement) bool {
 	if !element.deleted.CAS(0, 1) {
-		return // concurrent delete of the item in progress
+		return false // concurrent delete of the item in progress
+	}
+	element.seal()
+
+	l.unlink(element)
+	atomic.AddUintptr(&l.count, ^uintptr(0)) // decrease counter
+	return true
+}
+
+// DeleteIf deletes an element from the list if fn returns true for its value and the value did not
+// change until the element got deleted. Updates of the value that happen while the element gets
+// deleted fail. Returns true if the element was deleted.
+func (l *List) DeleteIf(element *ListElement, fn func(value interface{}) bool) bool {
+	old := atomic.LoadPointer(&element.value)
+	if element.Deleted() || element.deleting(old) || !fn(*(*interface{})(old)) {
+		return false
 	}
 
+	// store a copy of the value that lets concurrent updates fail until the element is deleted
+	value := *(*interface{})(old)
+	pending := unsafe.Pointer(&value)
+	if !atomic.CompareAndSwapPointer(&element.pending, nil, pending) {
+		return false // another conditional delete is in progress
+	}
+	if !atomic.CompareAndSwapPointer(&element.value, old, pending) {
+		atomic.StorePointer(&element.pending, nil)
+		return false // value was changed concurrently
+	}
+	return l.Delete(element)
+}
+
+// unlink removes a deleted element from the list. The element is marked first by linking a marker
+// element behind it, which lets any insert behind the element fail, then it is unlinked from its predecessor.
+// Any goroutine can help to unlink an element.
+func (l *List) unlink(element *ListElement) {
+	var marker *ListElement
 	for {
-		left := element.Previous()
-		right := element.Next()
+		next := element.rawNext()
+		if next != nil && next.marker {
+			marker = next // already marked
+			break
+		}
+		marker = &ListElement{key: element.key, nextElement: unsafe.Pointer(next), marker: true}
+		if atomic.CompareAndSwapPointer(&element.nextElement, unsafe.Pointer(next), unsafe.Pointer(marker)) {
+			break
+		}
+	}
 
-		if left == nil { // element is first item in list?
-			if !atomic.CompareAndSwapPointer(&l.head.nextElement, unsafe.Pointer(element), unsafe.Pointer(right)) {
-				continue // now head item was inserted concurrently
-			}
-		} else {
-			if !atomic.CompareAndSwapPointer(&left.nextElement, unsafe.Pointer(element), unsafe.Pointer(right)) {
-				continue // item was modified concurrently
+	right := marker.rawNext()
+	for {
+		left := l.predecessor(element)
+		if left == nil {
+			return // unlinked already
+		}
+		if atomic.CompareAndSwapPointer(&left.nextElement, unsafe.Pointer(element), unsafe.Pointer(right)) {
+			if right != nil {
+				atomic.CompareAndSwapPointer(&right.previousElement, unsafe.Pointer(element), unsafe.Pointer(left))
 			}
+			return
 		}
-		if right != nil {
-			atomic.CompareAndSwapPointer(&right.previousElement, unsafe.Pointer(element), unsafe.Pointer(left))
+	}
+}
+
+// predecessor returns the element linked to element, or nil if element is not linked anymore.
+func (l *List) predecessor(element *ListElement) *ListElement {
+	key := element.Key()
+	searchStart := element
+Retry:
+	for {
+		left := l.start(searchStart, key)
+		searchStart = nil         // restart at the head if the search needs to be retried
+		var previous *ListElement // element left of left if known
+		for {
+			next := left.rawNext()
+			if next == element {
+				return left
+			}
+			if next != nil && next.marker { // left is being deleted
+				if previous != nil { // help unlinking it
+					atomic.CompareAndSwapPointer(&previous.nextElement, unsafe.Pointer(left), unsafe.Pointer(next.rawNext()))
+				}
+				continue Retry
+			}
+			if next == nil || next.Key() > key {
+				return nil
+			}
+			previous, left = left, next
 		}
-		break
 	}
+}
 
-	atomic.AddUintptr(&l.count, ^uintptr(0)) // decrease counter
+// Check walks the list and returns an error if a key is stored more than once, the keys are not sorted
+// or the counter does not match the number of items. It must not be called concurrently to modifications.
+func (l *List) Check() error {
+	count := 0
+	var previous *ListElement
+	for item := l.First(); item != nil; item = item.Next() {
+		if previous != nil {
+			if item.key == previous.key {
+				return fmt.Errorf("sortedlist: duplicate key %d", item.Key())
+			}
+			if item.key < previous.key {
+				return fmt.Errorf("sortedlist: key %d is not sorted after %d", item.Key(), previous.Key())
+			}
+		}
+		previous = item
+		count++
+	}
+	if count != l.Len() {
+		return fmt.Errorf("sortedlist: counted %d items but length is %d", count, l.Len())
+	}
+	return nil
 }
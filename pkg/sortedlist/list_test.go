@@ -14,3 +14,59 @@ func TestListNew(t *testing.T) {
 		t.Error("Next element of empty list should be nil.")
 	}
 }
+
+func TestListInsertBehindDeleted(t *testing.T) {
+	l := New()
+	left := NewElement(1, "left")
+	right := NewElement(3, "right")
+	l.Add(left, nil)
+	l.Add(right, nil)
+
+	// simulate an insert that searched its position before left got deleted
+	l.Delete(left)
+	if l.insertAt(NewElement(2, "lost"), left, right) {
+		t.Error("element should not be linked behind a deleted element.")
+	}
+	if existed, inserted := l.Add(NewElement(2, "middle"), left); existed || !inserted {
+		t.Error("element should be inserted when searching from a deleted element.")
+	}
+	if err := l.Check(); err != nil {
+		t.Error(err)
+	}
+	if l.Len() != 2 || l.First().Key() != 2 || l.First().Next() != right {
+		t.Errorf("list should contain the keys 2 and 3 but has %d items.", l.Len())
+	}
+}
+
+func TestListReAddDeleted(t *testing.T) {
+	l := New()
+	first := NewElement(1, "first")
+	l.Add(first, nil)
+	first.deleted.Set(1) // deleted but not unlinked yet
+
+	if existed, inserted := l.Add(NewElement(1, "second"), nil); existed || !inserted {
+		t.Error("deleted element should be replaced.")
+	}
+	if l.First() == first || l.First().Value() != "second" {
+		t.Error("deleted element should be unlinked.")
+	}
+	if l.First().Next() != nil {
+		t.Error("list should contain exactly one element.")
+	}
+}
+
+func TestListAddOrUpdate(t *testing.T) {
+	l := New()
+	if updated, inserted := l.AddOrUpdate(NewElement(1, "a"), nil); updated || !inserted {
+		t.Error("new element should be inserted.")
+	}
+	if updated, inserted := l.AddOrUpdate(NewElement(1, "b"), nil); !updated || inserted {
+		t.Error("existing element should be updated.")
+	}
+	if l.First().Value() != "b" {
+		t.Error("value should be updated.")
+	}
+	if err := l.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -13,6 +13,8 @@ type ListElement struct {
 	key             atomic2.Uintptr
 	value           unsafe.Pointer
 	deleted         atomic2.Uintptr // marks the item as deleting or deleted
+	marker          bool            // marks the item as placeholder behind an item that gets unlinked
+	pending         unsafe.Pointer  // value of the item while a conditional delete is in progress
 }
 
 // NewElement returns an initialized list element.
@@ -35,26 +37,105 @@ func (e *ListElement) Key() uintptr {
 
 // Next returns the item on the right.
 func (e *ListElement) Next() *ListElement {
+	next := e.rawNext()
+	if next != nil && next.marker { // skip the marker of an item that gets unlinked
+		next = next.rawNext()
+	}
+	return next
+}
+
+// rawNext returns the item on the right including marker items.
+func (e *ListElement) rawNext() *ListElement {
 	return (*ListElement)(atomic.LoadPointer(&e.nextElement))
 }
 
+// marked returns true if a marker item is linked behind the item as it gets unlinked.
+func (e *ListElement) marked() bool {
+	next := e.rawNext()
+	return next != nil && next.marker
+}
+
+// Deleted returns true if the item is deleted or being deleted.
+func (e *ListElement) Deleted() bool {
+	return e.deleted.Get() != 0
+}
+
 // Previous returns the item on the left.
 func (e *ListElement) Previous() *ListElement {
 	return (*ListElement)(atomic.LoadPointer(&e.previousElement))
 }
 
-// setValue sets the value of the item.
+// setValue sets the value of the item and returns false if the item is deleted.
 // The value needs to be wrapped in unsafe.Pointer already.
-func (e *ListElement) setValue(value unsafe.Pointer) {
-	atomic.StorePointer(&e.value, value)
+func (e *ListElement) setValue(value unsafe.Pointer) bool {
+	for {
+		old := atomic.LoadPointer(&e.value)
+		if e.Deleted() || e.deleting(old) {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&e.value, old, value) {
+			return true
+		}
+	}
 }
 
-// casValue compares and swaps the values of the item.
+// casValue compares and swaps the values of the item, it fails if the item is deleted.
 // The to value needs to be wrapped in unsafe.Pointer already.
 func (e *ListElement) casValue(from interface{}, to unsafe.Pointer) bool {
 	old := atomic.LoadPointer(&e.value)
-	if *(*interface{})(old) != from {
+	if e.Deleted() || e.deleting(old) || *(*interface{})(old) != from {
 		return false
 	}
 	return atomic.CompareAndSwapPointer(&e.value, old, to)
 }
+
+// UpdateValue calls fn with the value of the item and replaces the value with the result of fn
+// if fn returns true. It returns false if the value was not replaced, which includes the value
+// being changed or the item being deleted concurrently.
+func (e *ListElement) UpdateValue(fn func(value interface{}) (interface{}, bool)) bool {
+	old := atomic.LoadPointer(&e.value)
+	if e.Deleted() || e.deleting(old) {
+		return false
+	}
+	value, ok := fn(*(*interface{})(old))
+	if !ok {
+		return false
+	}
+	return atomic.CompareAndSwapPointer(&e.value, old, unsafe.Pointer(&value))
+}
+
+// deleting returns true if value was stored by a conditional delete of the item that is in progress.
+func (e *ListElement) deleting(value unsafe.Pointer) bool {
+	return value == atomic.LoadPointer(&e.pending)
+}
+
+// seal replaces the value of a deleted item with a copy, which lets every value update
+// that loaded the value before the item got deleted fail.
+func (e *ListElement) seal() {
+	for {
+		old := atomic.LoadPointer(&e.value)
+		sealed := *(*interface{})(old)
+		if atomic.CompareAndSwapPointer(&e.value, old, unsafe.Pointer(&sealed)) {
+			return
+		}
+	}
+}
+
+// AtomicField is a field of a list element that is accessed atomically.
+type AtomicField struct {
+	Name    string
+	Address uintptr
+	Size    uintptr
+}
+
+// AtomicFields returns the fields of the element that are accessed atomically, in declaration order.
+func (e *ListElement) AtomicFields() []AtomicField {
+	return []AtomicField{
+		{Name: "ListElement.previousElement", Address: uintptr(unsafe.Pointer(&e.previousElement)), Size: unsafe.Sizeof(e.previousElement)},
+		{Name: "ListElement.nextElement", Address: uintptr(unsafe.Pointer(&e.nextElement)), Size: unsafe.Sizeof(e.nextElement)},
+		{Name: "ListElement.key", Address: uintptr(unsafe.Pointer(&e.key)), Size: unsafe.Sizeof(e.key)},
+		{Name: "ListElement.value", Address: uintptr(unsafe.Pointer(&e.value)), Size: unsafe.Sizeof(e.value)},
+		{Name: "ListElement.deleted", Address: uintptr(unsafe.Pointer(&e.deleted)), Size: unsafe.Sizeof(e.deleted)},
+		{Name: "ListElement.pending", Address: uintptr(unsafe.Pointer(&e.pending)), Size: unsafe.Sizeof(e.pending)},
+	}
+}
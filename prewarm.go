@@ -0,0 +1,68 @@
+package fastintmap
+
+import (
+	"github.com/itsabgr/fastintmap/pkg/sortedlist"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// prewarmSink keeps the reads of Prewarm from being optimized away.
+var prewarmSink uintptr
+
+// Prewarm reads every slot of the index and the first element of every bucket including its value so that they
+// are paged in and cached before the map serves traffic, using up to workers goroutines.
+// It is meant to be called after bulk loading the map, for example when restoring a snapshot,
+// and waits for a resize operation in progress to finish first.
+func (m *Map[T]) Prewarm(workers int) {
+	for atomic.LoadUintptr(&m.resizing) != 0 {
+		runtime.Gosched()
+	}
+	data := m.mapData()
+	if data == nil {
+		return
+	}
+	size := uintptr(len(data.index))
+	if workers < 1 {
+		workers = 1
+	}
+	if uintptr(workers) > size {
+		workers = int(size)
+	}
+	chunk := (size + uintptr(workers) - 1) / uintptr(workers)
+
+	var wg sync.WaitGroup
+	for start := uintptr(0); start < size; start += chunk {
+		end := start + chunk
+		if end > size {
+			end = size
+		}
+		wg.Add(1)
+		go func(start, end uintptr) {
+			defer wg.Done()
+			yield := m.yielder()
+			var sum uintptr
+			for index := start; index < end; index++ {
+				ptr := (*unsafe.Pointer)(unsafe.Pointer(uintptr(data.data) + index*intSizeBytes))
+				element := (*sortedlist.ListElement)(atomic.LoadPointer(ptr))
+				if element != nil {
+					sum += element.Key() + uintptr(firstByte(element.Value()))
+				}
+				yield()
+			}
+			atomic.AddUintptr(&prewarmSink, sum)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// firstByte returns the first byte of the memory the interface value points to,
+// which is the value itself or the target of a pointer value.
+func firstByte(value interface{}) byte {
+	data := (*[2]unsafe.Pointer)(unsafe.Pointer(&value))[1]
+	if data == nil {
+		return 0
+	}
+	return *(*byte)(data)
+}
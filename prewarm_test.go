@@ -0,0 +1,46 @@
+package fastintmap
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrewarm(t *testing.T) {
+	m := &Map[byte]{}
+	m.Prewarm(4)
+
+	m = New[byte](64)
+	var expected uintptr
+	for i := 0; i < 64; i++ { // one element for every slot of the index
+		key := uintptr(i) << (strconv.IntSize - 6)
+		m.Set(key, byte(i+1))
+		expected += key + uintptr(i+1)
+	}
+	for _, workers := range []int{0, 1, 3, 1000} {
+		atomic.StoreUintptr(&prewarmSink, 0)
+		m.Prewarm(workers)
+		if sum := atomic.LoadUintptr(&prewarmSink); sum != expected {
+			t.Errorf("Prewarm with %d workers should read every slot and value but read sum %d instead of %d.", workers, sum, expected)
+		}
+	}
+}
+
+func TestPrewarmWaitsForResize(t *testing.T) {
+	m := New[byte](8)
+	atomic.StoreUintptr(&m.resizing, 1) // simulate a resize in progress
+
+	done := make(chan struct{})
+	go func() {
+		m.Prewarm(1)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Prewarm should wait for the resize to finish.")
+	case <-time.After(20 * time.Millisecond):
+	}
+	atomic.StoreUintptr(&m.resizing, 0)
+	<-done
+}
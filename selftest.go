@@ -0,0 +1,195 @@
+package fastintmap
+
+import (
+	"fmt"
+	"github.com/itsabgr/fastintmap/pkg/sortedlist"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// Thresholds above which SelfTest reports an anomaly.
+const (
+	maxTimerResolution = time.Millisecond
+	maxGetLatency      = 10 * time.Microsecond
+)
+
+// Check is the result of a single SelfTest check.
+type Check struct {
+	Name   string
+	Detail string // measured value or description of the check
+	Err    error  // non-nil if the check found an anomaly
+	Warn   error  // non-nil if a performance limit was exceeded, which can be caused by a loaded machine
+}
+
+// Report is the result of SelfTest.
+type Report struct {
+	Checks []Check
+}
+
+// OK returns true if no check found an anomaly.
+func (r Report) OK() bool {
+	return len(r.Anomalies()) == 0
+}
+
+// Anomalies returns the checks that found an anomaly.
+func (r Report) Anomalies() []Check {
+	var anomalies []Check
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			anomalies = append(anomalies, check)
+		}
+	}
+	return anomalies
+}
+
+// Warnings returns the checks that exceeded a performance limit.
+func (r Report) Warnings() []Check {
+	var warnings []Check
+	for _, check := range r.Checks {
+		if check.Warn != nil {
+			warnings = append(warnings, check)
+		}
+	}
+	return warnings
+}
+
+// String returns the report as one line per check.
+func (r Report) String() string {
+	s := ""
+	for _, check := range r.Checks {
+		status := "ok"
+		if check.Err != nil {
+			status = check.Err.Error()
+		} else if check.Warn != nil {
+			status = "warning: " + check.Warn.Error()
+		}
+		s += fmt.Sprintf("%s: %s (%s)\n", check.Name, status, check.Detail)
+	}
+	return s
+}
+
+// SelfTest runs quick consistency and performance checks of the map implementation on the current
+// platform and reports anomalies. Exceeded performance limits are reported as warnings only.
+// It is meant to be called once at startup.
+func SelfTest() Report {
+	return Report{Checks: []Check{
+		checkAtomicAlignment(atomicFields()),
+		checkIndexMath(),
+		checkRoundTrip(),
+		checkTimerResolution(),
+	}}
+}
+
+// atomicFields returns the fields of a populated map that are accessed atomically, including a 64-bit
+// counter stored by pointer like the counter example does.
+func atomicFields() []sortedlist.AtomicField {
+	m := New[*int64](DefaultSize)
+	m.Set(1, new(int64))
+	counter, _ := m.Get(1)
+	data := m.mapData()
+	fields := []sortedlist.AtomicField{
+		{Name: "Map.dataMap", Address: uintptr(unsafe.Pointer(&m.dataMap)), Size: unsafe.Sizeof(m.dataMap)},
+		{Name: "Map.listPtr", Address: uintptr(unsafe.Pointer(&m.listPtr)), Size: unsafe.Sizeof(m.listPtr)},
+		{Name: "Map.resizing", Address: uintptr(unsafe.Pointer(&m.resizing)), Size: unsafe.Sizeof(m.resizing)},
+		{Name: "Map.version", Address: uintptr(unsafe.Pointer(&m.version)), Size: unsafe.Sizeof(m.version)},
+		{Name: "Map.migrating", Address: uintptr(unsafe.Pointer(&m.migrating)), Size: unsafe.Sizeof(m.migrating)},
+		{Name: "Map.migration", Address: uintptr(unsafe.Pointer(&m.migration)), Size: unsafe.Sizeof(m.migration)},
+		{Name: "Map.yieldEvery", Address: uintptr(unsafe.Pointer(&m.yieldEvery)), Size: unsafe.Sizeof(m.yieldEvery)},
+		{Name: "hashMapData.count", Address: uintptr(unsafe.Pointer(&data.count)), Size: unsafe.Sizeof(data.count)},
+		{Name: "int64 value", Address: uintptr(unsafe.Pointer(counter)), Size: unsafe.Sizeof(*counter)},
+	}
+	return append(fields, m.list().First().AtomicFields()...)
+}
+
+// checkAtomicAlignment verifies that every field is aligned to its size, which the atomic operations
+// on 64-bit fields require on 32-bit platforms.
+func checkAtomicAlignment(fields []sortedlist.AtomicField) Check {
+	check := Check{Name: "atomic-alignment", Detail: fmt.Sprintf("%d fields, word size %d bytes", len(fields), unsafe.Sizeof(uintptr(0)))}
+	for _, field := range fields {
+		if field.Address%field.Size != 0 {
+			check.Err = fmt.Errorf("field %s of %d bytes is at address %#x", field.Name, field.Size, field.Address)
+			return check
+		}
+	}
+	return check
+}
+
+// checkIndexMath verifies the index calculations for the word size of the current GOARCH.
+func checkIndexMath() Check {
+	check := Check{Name: "index-math", Detail: fmt.Sprintf("int size %d bits", strconv.IntSize)}
+	if intSizeBytes != unsafe.Sizeof(uintptr(0)) || intSizeBytes != unsafe.Sizeof(unsafe.Pointer(nil)) {
+		check.Err = fmt.Errorf("int size %d bytes does not match pointer size %d bytes", intSizeBytes, unsafe.Sizeof(uintptr(0)))
+		return check
+	}
+	for size := uintptr(1); size != 0 && size <= 1<<30; size <<= 1 {
+		if roundUpPower2(size) != size || roundUpPower2(size+1) != size<<1 {
+			check.Err = fmt.Errorf("roundUpPower2 is wrong for %d", size)
+			return check
+		}
+		shifts := strconv.IntSize - log2(size)
+		if last := ^uintptr(0) >> shifts; last != size-1 {
+			check.Err = fmt.Errorf("highest index for size %d is %d", size, last)
+			return check
+		}
+	}
+	return check
+}
+
+// checkRoundTrip stores and reads keys spread over the whole key space and measures the read latency.
+func checkRoundTrip() Check {
+	const itemCount = 1 << 10
+	check := Check{Name: "round-trip"}
+	m := New[uintptr](itemCount * 2)
+	shifts := strconv.IntSize - log2(itemCount)
+	for i := uintptr(0); i < itemCount; i++ {
+		m.Set(i<<shifts|i, i)
+	}
+	start := time.Now()
+	for i := uintptr(0); i < itemCount; i++ {
+		value, ok := m.Get(i<<shifts | i)
+		if !ok || value != i {
+			check.Err = fmt.Errorf("key %d was not read back", i<<shifts|i)
+			return check
+		}
+	}
+	latency := time.Since(start) / itemCount
+	check.Detail = fmt.Sprintf("get latency %s", latency)
+	if m.Len() != itemCount {
+		check.Err = fmt.Errorf("length is %d instead of %d", m.Len(), itemCount)
+	} else if err := m.DedupCheck(); err != nil {
+		check.Err = err
+	} else if latency > maxGetLatency {
+		check.Warn = fmt.Errorf("get latency exceeds %s", maxGetLatency)
+	}
+	return check
+}
+
+// checkTimerResolution measures the smallest observable clock step used for time to live calculations.
+func checkTimerResolution() Check {
+	check := Check{Name: "timer-resolution"}
+	resolution := time.Duration(0)
+	previous := time.Now()
+	for i := 0; i < 1000; i++ {
+		now := time.Now()
+		step := now.Sub(previous)
+		if step < 0 {
+			check.Err = fmt.Errorf("clock went backwards by %s", -step)
+			return check
+		}
+		if step > 0 && (resolution == 0 || step < resolution) {
+			resolution = step
+		}
+		previous = now
+	}
+	if resolution == 0 { // clock did not move within the loop, wait for the next tick
+		for resolution == 0 {
+			resolution = time.Since(previous)
+		}
+	}
+	check.Detail = fmt.Sprintf("resolution %s", resolution)
+	if resolution > maxTimerResolution {
+		check.Err = fmt.Errorf("timer resolution exceeds %s", maxTimerResolution)
+	}
+	return check
+}
@@ -0,0 +1,55 @@
+package fastintmap
+
+import (
+	"errors"
+	"github.com/itsabgr/fastintmap/pkg/sortedlist"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	report := SelfTest()
+	if len(report.Checks) == 0 {
+		t.Fatal("report should contain checks.")
+	}
+	for _, check := range report.Checks {
+		if check.Err != nil {
+			t.Errorf("check %s failed: %v", check.Name, check.Err)
+		}
+	}
+	if !report.OK() {
+		t.Error("report without anomalies should be ok.")
+	}
+}
+
+func TestReportAnomalies(t *testing.T) {
+	report := Report{Checks: []Check{
+		{Name: "good"},
+		{Name: "slow", Warn: errors.New("slow")},
+	}}
+	if !report.OK() {
+		t.Error("report with a warning only should be ok.")
+	}
+	if warnings := report.Warnings(); len(warnings) != 1 || warnings[0].Name != "slow" {
+		t.Error("expected exactly the slow check as warning.")
+	}
+
+	report.Checks = append(report.Checks, Check{Name: "bad", Err: errors.New("anomaly")})
+	if report.OK() {
+		t.Error("report with an anomaly should not be ok.")
+	}
+	if anomalies := report.Anomalies(); len(anomalies) != 1 || anomalies[0].Name != "bad" {
+		t.Error("expected exactly the bad check as anomaly.")
+	}
+}
+
+func TestCheckAtomicAlignment(t *testing.T) {
+	fields := atomicFields()
+	if check := checkAtomicAlignment(fields); check.Err != nil {
+		t.Errorf("fields of the map should be aligned: %v", check.Err)
+	}
+
+	fields = append(fields, sortedlist.AtomicField{Name: "misaligned", Address: 4, Size: 8})
+	if check := checkAtomicAlignment(fields); check.Err == nil {
+		t.Error("misaligned 64-bit field should be reported.")
+	}
+}
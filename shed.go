@@ -0,0 +1,166 @@
+package fastintmap
+
+import (
+	"errors"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Runtime metrics sampled by WatchHeap, the first supported name of every list is used.
+var (
+	heapMetrics    = []string{"/gc/heap/live:bytes", "/memory/classes/heap/objects:bytes"}
+	pauseMetrics   = []string{"/sched/pauses/total/gc:seconds", "/gc/pauses:seconds"}
+	cyclesMetrics  = []string{"/gc/cycles/total:gc-cycles"}
+	supportedNames = func() map[string]metrics.ValueKind {
+		names := make(map[string]metrics.ValueKind)
+		for _, description := range metrics.All() {
+			names[description.Name] = description.Kind
+		}
+		return names
+	}()
+)
+
+var (
+	// ErrInvalidInterval is returned by WatchHeap if the interval is not positive.
+	ErrInvalidInterval = errors.New("fastintmap: invalid watch interval")
+	// ErrMetricUnsupported is returned by WatchHeap if the runtime does not provide a required metric.
+	ErrMetricUnsupported = errors.New("fastintmap: runtime metric unsupported")
+	// ErrInvalidLimits is returned by WatchHeap if neither the heap nor the pause limit is set.
+	ErrInvalidLimits = errors.New("fastintmap: invalid limits")
+	// ErrNilEvict is returned by WatchHeap if no evict function is given.
+	ErrNilEvict = errors.New("fastintmap: nil evict")
+)
+
+// HeapLimits configures when WatchHeap sheds entries.
+type HeapLimits struct {
+	// Heap is the size in bytes of the live heap above which entries are shed, 0 disables the limit.
+	Heap uint64
+	// GCPause is the duration of a garbage collection pause above which entries are shed, 0 disables the limit.
+	GCPause time.Duration
+	// Interval is the time between two samples of the runtime metrics.
+	Interval time.Duration
+}
+
+// Shed visits the entries in key order and deletes every entry for which evict returns true.
+// An entry is not deleted if its value changes after evict has been called for it.
+// Returns the number of deleted entries.
+func (m *Map[T]) Shed(evict func(key uintptr, value T) bool) int {
+	list := m.list()
+	if list == nil {
+		return 0
+	}
+	deleted := 0
+	item := list.First()
+	yield := m.yielder()
+	for item != nil {
+		key := item.Key()
+		if list.DeleteIf(item, func(value interface{}) bool { return evict(key, cast[T](value)) }) {
+			m.deleteElement(item)
+			deleted++
+		}
+		item = item.Next()
+		yield()
+	}
+	return deleted
+}
+
+// WatchHeap starts a goroutine that samples the runtime metrics every interval and runs Shed with evict
+// whenever the live heap exceeds the heap limit or a garbage collection pause exceeds the pause limit.
+// The metrics reflect a shed only after the garbage collector ran, so after a shed the limits are not
+// checked again until a garbage collection cycle that started after the shed has finished.
+// Call the returned function to stop watching; it is safe to call it more than once.
+func (m *Map[T]) WatchHeap(limits HeapLimits, evict func(key uintptr, value T) bool) (stop func(), err error) {
+	if evict == nil {
+		return nil, ErrNilEvict
+	}
+	if limits.Interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+	if limits.Heap == 0 && limits.GCPause <= 0 {
+		return nil, ErrInvalidLimits
+	}
+	cycles, err := lookupMetric(cyclesMetrics, metrics.KindUint64)
+	if err != nil {
+		return nil, err
+	}
+	samples := []metrics.Sample{{Name: cycles}}
+	heap, pause := -1, -1
+	if limits.Heap > 0 {
+		name, err := lookupMetric(heapMetrics, metrics.KindUint64)
+		if err != nil {
+			return nil, err
+		}
+		heap = len(samples)
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+	if limits.GCPause > 0 {
+		name, err := lookupMetric(pauseMetrics, metrics.KindFloat64Histogram)
+		if err != nil {
+			return nil, err
+		}
+		pause = len(samples)
+		samples = append(samples, metrics.Sample{Name: name})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(limits.Interval)
+		defer ticker.Stop()
+		var pauseCounts []uint64 // pause histogram counts of the previous sample
+		var shedCycle uint64
+		shed := false
+		for {
+			metrics.Read(samples)
+			pressure := false
+			if heap >= 0 && samples[heap].Value.Uint64() > limits.Heap {
+				pressure = true
+			}
+			if pause >= 0 {
+				histogram := samples[pause].Value.Float64Histogram()
+				if pauseCounts != nil && maxNewPause(histogram, pauseCounts) > limits.GCPause.Seconds() {
+					pressure = true
+				}
+				pauseCounts = append(pauseCounts[:0], histogram.Counts...)
+			}
+			// the cycle count increases when a cycle finishes, which might have started before the shed
+			cycle := samples[0].Value.Uint64()
+			if pressure && (!shed || cycle >= shedCycle+2) {
+				m.Shed(evict)
+				shed, shedCycle = true, cycle
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}, nil
+}
+
+// lookupMetric returns the first of the metric names the runtime supports with the given kind.
+func lookupMetric(names []string, kind metrics.ValueKind) (string, error) {
+	for _, name := range names {
+		if supportedNames[name] == kind {
+			return name, nil
+		}
+	}
+	return "", ErrMetricUnsupported
+}
+
+// maxNewPause returns the lower bound in seconds of the longest pause recorded in histogram
+// since the histogram had the previous counts.
+func maxNewPause(histogram *metrics.Float64Histogram, previous []uint64) float64 {
+	for i := len(histogram.Counts) - 1; i >= 0; i-- {
+		if i >= len(previous) || histogram.Counts[i] > previous[i] {
+			return histogram.Buckets[i]
+		}
+	}
+	return math.Inf(-1)
+}
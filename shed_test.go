@@ -0,0 +1,138 @@
+package fastintmap
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShed(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 100; i++ {
+		m.Set(uintptr(i), i)
+	}
+	deleted := m.Shed(func(key uintptr, value int) bool {
+		return value%2 == 0
+	})
+	if deleted != 50 {
+		t.Errorf("expected 50 deleted entries but got %d.", deleted)
+	}
+	if m.Len() != 50 {
+		t.Errorf("expected 50 remaining entries but got %d.", m.Len())
+	}
+	if _, ok := m.Get(uintptr(2)); ok {
+		t.Error("shed item should not exist.")
+	}
+	if _, ok := m.Get(uintptr(3)); !ok {
+		t.Error("kept item should exist.")
+	}
+}
+
+func TestShedConcurrentSet(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 10; i++ {
+		m.Set(uintptr(i), i)
+	}
+	deleted := m.Shed(func(key uintptr, value int) bool {
+		if key == 3 {
+			m.Set(3, 33) // refreshed after evict decided on the old value
+		}
+		return true
+	})
+	if deleted != 9 {
+		t.Errorf("expected 9 deleted entries but got %d.", deleted)
+	}
+	if value, ok := m.Get(3); !ok || value != 33 {
+		t.Errorf("refreshed entry should be kept but got %d %t.", value, ok)
+	}
+}
+
+func TestWatchHeap(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 100; i++ {
+		m.Set(uintptr(i), i)
+	}
+	runtime.GC() // the live heap is known after the first garbage collection
+
+	var sheds int64
+	stop, err := m.WatchHeap(HeapLimits{Heap: 1, Interval: time.Millisecond}, func(key uintptr, value int) bool {
+		if key == 0 { // every shed starts with the smallest key
+			atomic.AddInt64(&sheds, 1)
+		}
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitSheds := func(n int64) {
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt64(&sheds) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("map should have been shed %d times but was shed %d times.", n, atomic.LoadInt64(&sheds))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	gcCycles := func() int64 {
+		sample := []metrics.Sample{{Name: cyclesMetrics[0]}}
+		metrics.Read(sample)
+		return int64(sample[0].Value.Uint64())
+	}
+	waitSheds(1)
+	before, cycles := atomic.LoadInt64(&sheds), gcCycles()
+	time.Sleep(50 * time.Millisecond)
+	// a shed is repeated at most every second garbage collection cycle, not on every sample
+	if extra, cycles := atomic.LoadInt64(&sheds)-before, gcCycles()-cycles; extra > cycles/2+1 {
+		t.Errorf("map was shed %d more times within %d garbage collection cycles.", extra, cycles)
+	}
+
+	n := atomic.LoadInt64(&sheds)
+	runtime.GC()
+	runtime.GC()
+	waitSheds(n + 1)
+	stop()
+}
+
+func TestWatchHeapGCPause(t *testing.T) {
+	m := &Map[int]{}
+	for i := 0; i < 100; i++ {
+		m.Set(uintptr(i), i)
+	}
+	stop, err := m.WatchHeap(HeapLimits{GCPause: time.Nanosecond, Interval: time.Millisecond}, func(key uintptr, value int) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	deadline := time.Now().Add(time.Second)
+	for m.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("map should have been shed but has %d items.", m.Len())
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchHeapInvalid(t *testing.T) {
+	m := &Map[int]{}
+	evict := func(key uintptr, value int) bool { return true }
+	if _, err := m.WatchHeap(HeapLimits{Heap: 1, Interval: time.Millisecond}, nil); err != ErrNilEvict {
+		t.Errorf("expected ErrNilEvict but got %v.", err)
+	}
+	if _, err := m.WatchHeap(HeapLimits{Heap: 1}, evict); err != ErrInvalidInterval {
+		t.Errorf("expected ErrInvalidInterval but got %v.", err)
+	}
+	if _, err := m.WatchHeap(HeapLimits{Interval: time.Millisecond}, evict); err != ErrInvalidLimits {
+		t.Errorf("expected ErrInvalidLimits but got %v.", err)
+	}
+	if _, err := lookupMetric([]string{"/unknown:bytes"}, metrics.KindUint64); err != ErrMetricUnsupported {
+		t.Errorf("expected ErrMetricUnsupported but got %v.", err)
+	}
+}